@@ -16,141 +16,74 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
-	"github.com/google/uuid"
+	"github.com/jhernand/acme-hardware-management-plugin/driver"
+	"github.com/jhernand/acme-hardware-management-plugin/finalizers"
+	"github.com/jhernand/acme-hardware-management-plugin/metrics"
+	genreconcile "github.com/jhernand/acme-hardware-management-plugin/reconcile"
 	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"k8s.io/client-go/tools/record"
 	clnt "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	crfinalizer "sigs.k8s.io/controller-runtime/pkg/finalizer"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-// AllocationReconciler contains the data and logic needed to reconcile request to allocate nodes.
+// noCapacityRequeueInterval is how long we wait before checking again whether capacity has
+// become available, when an allocation request can't be fulfilled immediately.
+const noCapacityRequeueInterval = 30 * time.Second
+
+// AllocationReconciler contains the data and logic needed to reconcile request to allocate
+// nodes. The fetch, finalize and patch boilerplate lives in the embedded generic reconciler;
+// this type only provides the update and delete callbacks.
 type AllocationReconciler struct {
-	logger logr.Logger
+	*genreconcile.Reconciler[*pluginapi.NodeAllocationRequest]
 	client clnt.Client
+	driver driver.HardwareDriver
+	events record.EventRecorder
 }
 
-// Reconcile is the method that will be called by the controller runtime library when a request
-// to allocate a node has been created, updated or deleted.
-func (r *AllocationReconciler) Reconcile(ctx context.Context,
-	request ctrl.Request) (result ctrl.Result, err error) {
-	// Fetch the object object:
-	object := &pluginapi.NodeAllocationRequest{}
-	err = r.client.Get(ctx, request.NamespacedName, object)
-	if apierrors.IsNotFound(err) {
-		r.logger.Info(
-			"Object no longer exists",
-			"namespace", request.Namespace,
-			"name", request.Name,
-		)
-		err = nil
-		return
+// NewAllocationReconciler creates a reconciler for allocation requests, and registers its
+// finalizer, which releases the BMC state of the allocated node and deletes its credentials
+// secret. Nodes are allocated and released through the given driver, and allocation and cleanup
+// outcomes are reported through the given event recorder.
+func NewAllocationReconciler(logger logr.Logger, client clnt.Client, hardware driver.HardwareDriver,
+	events record.EventRecorder) (result *AllocationReconciler, err error) {
+	r := &AllocationReconciler{
+		client: client,
+		driver: hardware,
+		events: events,
 	}
+	registry := finalizers.NewRegistry()
+	err = registry.Register(finalizerName, r.processDelete)
 	if err != nil {
-		r.logger.Error(err, "Failed to get object")
-		return
-	}
-
-	// Check if the object is being deleted and if it has our finalizer:
-	deleting := !object.DeletionTimestamp.IsZero()
-	finalizer := controllerutil.ContainsFinalizer(object, finalizerName)
-
-	// Make a copy of the object so that we can modify it during our processing, and calculate
-	// the changes from the original to make a patch when we are done.
-	copy := object.DeepCopy()
-
-	// If the object isn't being deleted and doesn't have our finalizeer then we need to add
-	// the finalizer and save it inmediately, so that when it is eventually deleted we will
-	// have time to do our cleanup actions. This will generate another call to our reconciler
-	// where we will do the real work.
-	if !deleting && !finalizer {
-		controllerutil.AddFinalizer(copy, finalizerName)
-		err = r.client.Patch(ctx, copy, clnt.MergeFrom(object))
-		if err != nil {
-			r.logger.Error(
-				err,
-				"Failed to add finalizer",
-				"namespace", request.Namespace,
-				"name", request.Name,
-				"finalizer", finalizerName,
-			)
-		}
-		return
-	}
-
-	// If the object is being deleted then we need to do our cleaning actions, save the updated
-	// status and remove the finalizer.
-	if deleting {
-		result, err = r.processDelete(ctx, copy)
-		if err != nil {
-			return
-		}
-		err = r.client.Status().Patch(ctx, copy, clnt.MergeFrom(object))
-		if err != nil {
-			r.logger.Error(
-				err,
-				"Failed to updated status",
-				"namespace", request.Namespace,
-				"name", request.Name,
-			)
-			return
-		}
-		controllerutil.RemoveFinalizer(copy, finalizerName)
-		err = r.client.Patch(ctx, copy, clnt.MergeFrom(object))
-		if err != nil {
-			r.logger.Error(
-				err,
-				"Failed to remove finalizer",
-				"namespace", request.Namespace,
-				"name", request.Name,
-				"finalizer", finalizerName,
-			)
-		}
 		return
 	}
-
-	// If we are here then the object was just created or updated, and it already has our
-	// finalizer, so we must do our update processing and save the updated status.
-	result, err = r.processUpdate(ctx, copy)
-	if err != nil {
-		r.logger.Error(
-			err,
-			"Failed to process update",
-			"namespace", request.Namespace,
-			"name", request.Name,
-		)
-		return
-	}
-	err = r.client.Status().Patch(ctx, copy, clnt.MergeFrom(object))
-	if err != nil {
-		r.logger.Error(
-			err,
-			"Failed to updated status",
-			"namespace", request.Namespace,
-			"name", request.Name,
-		)
-		return
+	r.Reconciler = &genreconcile.Reconciler[*pluginapi.NodeAllocationRequest]{
+		Logger: logger,
+		Client: client,
+		New: func() *pluginapi.NodeAllocationRequest {
+			return &pluginapi.NodeAllocationRequest{}
+		},
+		Finalizers:    registry,
+		ProcessUpdate: r.processUpdate,
 	}
-	r.logger.Info(
-		"Saved updated status",
-		"namespace", request.Namespace,
-		"name", request.Name,
-	)
-
+	result = r
 	return
 }
 
 func (r *AllocationReconciler) processUpdate(ctx context.Context,
 	object *pluginapi.NodeAllocationRequest) (result reconcile.Result, err error) {
 	// Inform in the log that we are fulfilling the request:
-	r.logger.Info(
+	r.Logger.Info(
 		"Fulfilling request",
 		"namespace", object.Namespace,
 		"name", object.Name,
@@ -159,48 +92,81 @@ func (r *AllocationReconciler) processUpdate(ctx context.Context,
 		"extensions", object.Spec.Extensions,
 	)
 
-	// Do the actual processing ...
+	// Remember whether the request was already fulfilled, so that we only count and report the
+	// allocation once, rather than on every reconcile.
+	alreadyFulfilled := meta.IsStatusConditionTrue(object.Status.Conditions, pluginapi.FulfilledCondition)
 
-	// If the node identifier is not yet assiged we should assign it now. Note that in this
-	// example it is just a random UUID, but in reality it should be an identifier that allows
-	// the hardware manager to identify the node in later requests to update or release it.
+	// If the node identifier is not yet assigned we should allocate it now, and create the
+	// secret containing the BMC credentials returned by the driver. Once a node has been
+	// allocated we don't try to allocate it again, or touch its credentials, on every
+	// reconcile.
 	if object.Status.NodeID == "" {
-		object.Status.NodeID = uuid.NewString()
-	}
-
-	// Create or update the secret containing the BMC credentials of the node. The secret will
-	// be in the same namespace than the allocation request, and the name will be the name of
-	// the allocation request followed with a `-bmc` suffix.
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Namespace: object.Namespace,
-			Name:      fmt.Sprintf("%s-bmc", object.Name),
-		},
-	}
-	_, err = controllerutil.CreateOrPatch(ctx, r.client, secret, func() error {
-		err := controllerutil.SetOwnerReference(object, secret, r.client.Scheme())
+		var id driver.NodeID
+		var address driver.BMCAddress
+		var credentials driver.Credentials
+		start := time.Now()
+		id, address, credentials, err = r.driver.Allocate(ctx, object.Spec, string(object.UID))
+		metrics.BMCRequestDuration.WithLabelValues(r.driver.Name(), "allocate").Observe(time.Since(start).Seconds())
+		if errors.Is(err, driver.ErrNoCapacity) {
+			r.Logger.Info(
+				"No capacity available",
+				"namespace", object.Namespace,
+				"name", object.Name,
+			)
+			metrics.AllocationsTotal.WithLabelValues("no_capacity").Inc()
+			r.events.Event(object, corev1.EventTypeWarning, "NoCapacity",
+				"No node currently satisfies the requested location and extensions")
+			meta.SetStatusCondition(&object.Status.Conditions, metav1.Condition{
+				Type:    pluginapi.FulfilledCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  "NoCapacity",
+				Message: "No node currently satisfies the requested location and extensions",
+			})
+			result.RequeueAfter = noCapacityRequeueInterval
+			err = nil
+			return
+		}
 		if err != nil {
-			return err
+			metrics.AllocationsTotal.WithLabelValues("error").Inc()
+			r.events.Eventf(object, corev1.EventTypeWarning, "AllocationFailed", "Failed to allocate node: %v", err)
+			return
 		}
-		if secret.Data == nil {
-			secret.Data = map[string][]byte{}
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: object.Namespace,
+				Name:      fmt.Sprintf("%s-bmc", object.Name),
+			},
 		}
-		secret.Data["username"] = []byte("myuser")
-		secret.Data["password"] = []byte("mypass")
-		return nil
-	})
-	if err != nil {
-		return
-	}
-	r.logger.Info(
-		"Created BMC credentials secret",
-		"namespace", secret.Namespace,
-		"name", secret.Name,
-	)
+		_, err = controllerutil.CreateOrPatch(ctx, r.client, secret, func() error {
+			err := controllerutil.SetOwnerReference(object, secret, r.client.Scheme())
+			if err != nil {
+				return err
+			}
+			secret.Data = map[string][]byte{
+				"username": []byte(credentials.Username),
+				"password": []byte(credentials.Password),
+			}
+			return nil
+		})
+		if err != nil {
+			metrics.AllocationsTotal.WithLabelValues("error").Inc()
+			r.events.Eventf(object, corev1.EventTypeWarning, "AllocationFailed",
+				"Failed to create BMC credentials secret: %v", err)
+			return
+		}
+		r.Logger.Info(
+			"Created BMC credentials secret",
+			"namespace", secret.Namespace,
+			"name", secret.Name,
+		)
+		r.events.Eventf(object, corev1.EventTypeNormal, "SecretCreated",
+			"Created BMC credentials secret %q", secret.Name)
 
-	// Set the reference to the BMC credentials and the rest of the BMC details:
-	object.Status.BMC.Address = "https://mybmc.com"
-	object.Status.BMC.CredentialsName = secret.Name
+		object.Status.NodeID = string(id)
+		object.Status.BMC.Address = string(address)
+		object.Status.BMC.CredentialsName = secret.Name
+	}
 
 	// Update the conditions:
 	meta.SetStatusCondition(&object.Status.Conditions, metav1.Condition{
@@ -211,7 +177,7 @@ func (r *AllocationReconciler) processUpdate(ctx context.Context,
 	})
 
 	// Inform in the log that the request is fulfilled:
-	r.logger.Info(
+	r.Logger.Info(
 		"Fulfilled request",
 		"namespace", object.Namespace,
 		"name", object.Name,
@@ -220,15 +186,92 @@ func (r *AllocationReconciler) processUpdate(ctx context.Context,
 		"extensions", object.Spec.Extensions,
 	)
 
+	if !alreadyFulfilled {
+		metrics.AllocationsTotal.WithLabelValues("success").Inc()
+		metrics.AllocationDuration.Observe(time.Since(object.CreationTimestamp.Time).Seconds())
+		r.events.Eventf(object, corev1.EventTypeNormal, "Allocated", "Allocated node %q", object.Status.NodeID)
+
+		// Only refresh the inventory gauge when we actually allocated a node. Refreshing it on
+		// every reconcile of an already-fulfilled request would mean a Redfish round-trip to the
+		// BMC just to keep a gauge warm.
+		if nodes, invErr := r.driver.Inventory(ctx); invErr == nil {
+			metrics.RecordNodesByState(nodes)
+		}
+	}
+
 	return
 }
 
+// processDelete releases the BMC state of the allocated node and deletes the secret containing
+// its credentials. It is registered as the finalizer function for allocation requests, so its
+// signature is dictated by finalizers.Func rather than by the reconciler itself.
 func (r *AllocationReconciler) processDelete(ctx context.Context,
-	object *pluginapi.NodeAllocationRequest) (result reconcile.Result, err error) {
-	r.logger.Info(
+	object clnt.Object) (result crfinalizer.Result, err error) {
+	allocation, ok := object.(*pluginapi.NodeAllocationRequest)
+	if !ok {
+		err = fmt.Errorf("expected a node allocation request but got object of type %T", object)
+		return
+	}
+	r.Logger.Info(
 		"Performing cleanup",
-		"namespace", object.GetNamespace(),
-		"name", object.GetName(),
+		"namespace", allocation.GetNamespace(),
+		"name", allocation.GetName(),
+	)
+
+	// Release the node back to the driver, if one was ever allocated. As soon as the release
+	// succeeds we clear the node identifier and patch it immediately, rather than letting the
+	// generic reconciler patch it later, because the generic reconciler only patches the status
+	// if this function returns without error, and a later failure in this function (for example
+	// deleting the secret below) must not cause a retry to find the identifier still set and
+	// release the same node, and double-count the metric and event, again.
+	released := false
+	if allocation.Status.NodeID != "" {
+		before := allocation.DeepCopy()
+		start := time.Now()
+		err = r.driver.Release(ctx, driver.NodeID(allocation.Status.NodeID))
+		metrics.BMCRequestDuration.WithLabelValues(r.driver.Name(), "release").Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.ReleasesTotal.WithLabelValues("error").Inc()
+			r.events.Eventf(allocation, corev1.EventTypeWarning, "ReleaseFailed",
+				"Failed to release node %q: %v", allocation.Status.NodeID, err)
+			return
+		}
+		metrics.ReleasesTotal.WithLabelValues("success").Inc()
+		r.events.Eventf(allocation, corev1.EventTypeNormal, "Released", "Released node %q", allocation.Status.NodeID)
+		allocation.Status.NodeID = ""
+		err = r.client.Status().Patch(ctx, allocation, clnt.MergeFrom(before))
+		if err != nil {
+			err = fmt.Errorf("failed to clear node identifier after release: %w", err)
+			return
+		}
+		released = true
+	}
+
+	// Delete the secret containing the BMC credentials of the node.
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: allocation.Namespace,
+			Name:      fmt.Sprintf("%s-bmc", allocation.Name),
+		},
+	}
+	err = r.client.Delete(ctx, secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return
+	}
+	err = nil
+	r.Logger.Info(
+		"Deleted BMC credentials secret",
+		"namespace", secret.Namespace,
+		"name", secret.Name,
 	)
+	r.events.Eventf(allocation, corev1.EventTypeNormal, "SecretDeleted", "Deleted BMC credentials secret %q", secret.Name)
+
+	// Only refresh the inventory gauge when we actually released a node.
+	if released {
+		if nodes, invErr := r.driver.Inventory(ctx); invErr == nil {
+			metrics.RecordNodesByState(nodes)
+		}
+	}
+
 	return
 }
@@ -0,0 +1,147 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/jhernand/acme-hardware-management-plugin/driver"
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestAllocateReleaseRoundTrip exercises a full allocation followed by a release against a fake
+// client and a fake driver seeded with a single node, verifying that the node ends up allocated
+// after the first and available again after the second.
+//
+// This uses the fake client rather than envtest deliberately: the `NodeAllocationRequest` and
+// `NodeReleaseRequest` CRDs are owned and versioned by the `oran-o2ims` module, and this repository
+// doesn't vendor their manifests, so there is nothing to pass to envtest's `CRDDirectoryPaths`
+// without copying and maintaining a second copy of CRDs that belong upstream. The fake client
+// still exercises the reconcilers' logic end to end; what it doesn't cover is CRD schema
+// validation and any admission/webhook path, which belong to `oran-o2ims`'s own test suite.
+func TestAllocateReleaseRoundTrip(t *testing.T) {
+	scheme := clnt.NewScheme()
+	err := pluginapi.AddToScheme(scheme)
+	if err != nil {
+		t.Fatalf("failed to add plugin API types to the scheme: %v", err)
+	}
+	err = corev1.AddToScheme(scheme)
+	if err != nil {
+		t.Fatalf("failed to add core types to the scheme: %v", err)
+	}
+
+	hardware := driver.NewFakeDriver(driver.Node{
+		ID:         "node-0",
+		Location:   "rack-1",
+		Extensions: map[string]string{"cpu_count": "64"},
+	})
+
+	allocation := &pluginapi.NodeAllocationRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "my-allocation",
+		},
+		Spec: pluginapi.NodeAllocationRequestSpec{
+			Location:   "rack-1",
+			Extensions: map[string]string{"cpu_count": "64"},
+		},
+	}
+	client := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(allocation).
+		WithStatusSubresource(allocation, &pluginapi.NodeReleaseRequest{}).
+		Build()
+
+	allocationReconciler, err := NewAllocationReconciler(logr.Discard(), client, hardware, record.NewFakeRecorder(10))
+	if err != nil {
+		t.Fatalf("failed to create allocation reconciler: %v", err)
+	}
+	ctx := context.Background()
+	request := ctrl.Request{
+		NamespacedName: clnt.ObjectKeyFromObject(allocation),
+	}
+
+	// The first reconcile only adds the finalizer, the second does the actual allocation:
+	_, err = allocationReconciler.Reconcile(ctx, request)
+	if err != nil {
+		t.Fatalf("failed to add finalizer: %v", err)
+	}
+	_, err = allocationReconciler.Reconcile(ctx, request)
+	if err != nil {
+		t.Fatalf("failed to allocate node: %v", err)
+	}
+
+	err = client.Get(ctx, request.NamespacedName, allocation)
+	if err != nil {
+		t.Fatalf("failed to get allocation: %v", err)
+	}
+	if allocation.Status.NodeID != "node-0" {
+		t.Fatalf("expected node 'node-0' to be allocated, got '%s'", allocation.Status.NodeID)
+	}
+
+	nodes, err := hardware.Inventory(ctx)
+	if err != nil {
+		t.Fatalf("failed to list inventory: %v", err)
+	}
+	if !nodes[0].Allocated {
+		t.Fatalf("expected node 'node-0' to be reported as allocated after the allocation request")
+	}
+
+	release := &pluginapi.NodeReleaseRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "my-release",
+		},
+		Spec: pluginapi.NodeReleaseRequestSpec{
+			NodeID: allocation.Status.NodeID,
+		},
+	}
+	err = client.Create(ctx, release)
+	if err != nil {
+		t.Fatalf("failed to create release: %v", err)
+	}
+
+	releaseReconciler, err := NewReleaseReconciler(logr.Discard(), client, hardware, record.NewFakeRecorder(10))
+	if err != nil {
+		t.Fatalf("failed to create release reconciler: %v", err)
+	}
+	releaseRequest := ctrl.Request{
+		NamespacedName: clnt.ObjectKeyFromObject(release),
+	}
+	_, err = releaseReconciler.Reconcile(ctx, releaseRequest)
+	if err != nil {
+		t.Fatalf("failed to add finalizer: %v", err)
+	}
+	_, err = releaseReconciler.Reconcile(ctx, releaseRequest)
+	if err != nil {
+		t.Fatalf("failed to release node: %v", err)
+	}
+
+	nodes, err = hardware.Inventory(ctx)
+	if err != nil {
+		t.Fatalf("failed to list inventory: %v", err)
+	}
+	if nodes[0].Allocated {
+		t.Fatalf("expected node 'node-0' to be available again after the release request")
+	}
+}
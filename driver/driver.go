@@ -0,0 +1,84 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+// Package driver defines the interface used by the reconcilers to talk to the actual hardware,
+// and the implementations of that interface.
+package driver
+
+import (
+	"context"
+	"errors"
+
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+)
+
+// ErrNoCapacity is returned by Allocate when no node currently satisfies the requested spec, as
+// opposed to other errors, which indicate that something went wrong talking to the backend.
+// Callers can use this to distinguish a transient backend failure, which should probably be
+// retried immediately, from a lack of capacity, which should be reported to the user and retried
+// later.
+var ErrNoCapacity = errors.New("no node currently satisfies the requested spec")
+
+// NodeID is the identifier that a driver uses to refer to a node in later calls to Release, and
+// that we store in the status of the allocation request so that the release request can use it.
+type NodeID string
+
+// BMCAddress is the URL used to reach the BMC of a node.
+type BMCAddress string
+
+// Credentials are the username and password used to authenticate with the BMC of a node.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Node describes one of the nodes known to a driver, as returned by Inventory.
+type Node struct {
+	// ID is the driver-specific identifier of the node.
+	ID NodeID
+
+	// Location is the physical location of the node, in the same format used in the `Location`
+	// field of allocation requests.
+	Location string
+
+	// Extensions contains the node attributes, such as CPU count, RAM or NIC labels, in the
+	// same format used in the `Extensions` field of allocation requests.
+	Extensions map[string]string
+
+	// Allocated indicates whether the node is unavailable for allocation: it is already
+	// allocated to some other request or, in drivers that track it, draining.
+	Allocated bool
+}
+
+// HardwareDriver is implemented by the different backends that can fulfil allocation and release
+// requests. Reconcilers don't talk to the hardware directly; they always go through one of these.
+type HardwareDriver interface {
+	// Allocate reserves a node that satisfies the given spec on behalf of the given owner, which
+	// is the UID of the allocation request, and returns the identifier of the node, the address
+	// of its BMC and the credentials needed to use it. It returns ErrNoCapacity if no node
+	// currently satisfies the spec.
+	Allocate(ctx context.Context, spec pluginapi.NodeAllocationRequestSpec,
+		owner string) (id NodeID, address BMCAddress, credentials Credentials, err error)
+
+	// Release returns the node with the given identifier to the pool of available nodes.
+	Release(ctx context.Context, id NodeID) error
+
+	// Inventory returns the set of nodes known to the driver, whether they are currently
+	// allocated or not.
+	Inventory(ctx context.Context) ([]Node, error)
+
+	// Name identifies the driver implementation, for example "mock" or "redfish". It is used to
+	// label metrics, and shouldn't change for the lifetime of the driver.
+	Name() string
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+)
+
+// FakeDriver is a `HardwareDriver` backed by an in-memory list of nodes, intended for use in
+// tests that need to exercise the allocate/release round trip without a real cluster or BMCs.
+type FakeDriver struct {
+	nodes     []Node
+	allocated map[NodeID]string
+}
+
+var _ HardwareDriver = (*FakeDriver)(nil)
+
+// NewFakeDriver creates a fake driver seeded with the given nodes.
+func NewFakeDriver(nodes ...Node) *FakeDriver {
+	return &FakeDriver{
+		nodes:     nodes,
+		allocated: map[NodeID]string{},
+	}
+}
+
+// Allocate implements the HardwareDriver interface. It picks the first node whose location and
+// extensions satisfy the spec and that isn't already allocated.
+func (d *FakeDriver) Allocate(ctx context.Context, spec pluginapi.NodeAllocationRequestSpec,
+	owner string) (id NodeID, address BMCAddress, credentials Credentials, err error) {
+	for _, node := range d.nodes {
+		if d.allocated[node.ID] != "" {
+			continue
+		}
+		if spec.Location != "" && spec.Location != node.Location {
+			continue
+		}
+		if !matchesExtensions(spec.Extensions, node.Extensions) {
+			continue
+		}
+		d.allocated[node.ID] = owner
+		id = node.ID
+		address = BMCAddress(fmt.Sprintf("https://%s.example.com", node.ID))
+		credentials = Credentials{
+			Username: "fakeuser",
+			Password: "fakepass",
+		}
+		return
+	}
+	err = fmt.Errorf("%w: no node matches location %q and extensions %v", ErrNoCapacity, spec.Location, spec.Extensions)
+	return
+}
+
+// Release implements the HardwareDriver interface.
+func (d *FakeDriver) Release(ctx context.Context, id NodeID) error {
+	delete(d.allocated, id)
+	return nil
+}
+
+// Inventory implements the HardwareDriver interface.
+func (d *FakeDriver) Inventory(ctx context.Context) ([]Node, error) {
+	result := make([]Node, len(d.nodes))
+	for i, node := range d.nodes {
+		node.Allocated = d.allocated[node.ID] != ""
+		result[i] = node
+	}
+	return result, nil
+}
+
+// Name implements the HardwareDriver interface.
+func (d *FakeDriver) Name() string {
+	return "fake"
+}
+
+func matchesExtensions(wanted, actual map[string]string) bool {
+	for key, value := range wanted {
+		if actual[key] != value {
+			return false
+		}
+	}
+	return true
+}
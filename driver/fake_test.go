@@ -0,0 +1,71 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package driver
+
+import (
+	"context"
+	"testing"
+
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+)
+
+// TestFakeDriverAllocateRelease verifies that a node allocated by the fake driver is reported as
+// allocated in the inventory, and becomes available again once released.
+func TestFakeDriverAllocateRelease(t *testing.T) {
+	driver := NewFakeDriver(Node{
+		ID:         "node-0",
+		Location:   "rack-1",
+		Extensions: map[string]string{"cpu_count": "64"},
+	})
+
+	ctx := context.Background()
+	spec := pluginapi.NodeAllocationRequestSpec{
+		Location:   "rack-1",
+		Extensions: map[string]string{"cpu_count": "64"},
+	}
+	id, _, _, err := driver.Allocate(ctx, spec, "owner-1")
+	if err != nil {
+		t.Fatalf("failed to allocate node: %v", err)
+	}
+	if id != "node-0" {
+		t.Fatalf("expected node 'node-0' to be allocated, got '%s'", id)
+	}
+
+	nodes, err := driver.Inventory(ctx)
+	if err != nil {
+		t.Fatalf("failed to list inventory: %v", err)
+	}
+	if !nodes[0].Allocated {
+		t.Fatalf("expected node 'node-0' to be reported as allocated")
+	}
+
+	_, _, _, err = driver.Allocate(ctx, spec, "owner-2")
+	if err == nil {
+		t.Fatalf("expected allocating an already allocated node to fail")
+	}
+
+	err = driver.Release(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to release node: %v", err)
+	}
+
+	nodes, err = driver.Inventory(ctx)
+	if err != nil {
+		t.Fatalf("failed to list inventory: %v", err)
+	}
+	if nodes[0].Allocated {
+		t.Fatalf("expected node 'node-0' to no longer be allocated")
+	}
+}
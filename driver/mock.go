@@ -0,0 +1,61 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package driver
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+)
+
+// MockDriver is a `HardwareDriver` that doesn't talk to any real hardware. It just generates a
+// random node identifier and returns fixed BMC details, preserving the behavior that the plugin
+// had before the introduction of the driver interface.
+type MockDriver struct{}
+
+var _ HardwareDriver = (*MockDriver)(nil)
+
+// NewMockDriver creates a mock driver.
+func NewMockDriver() *MockDriver {
+	return &MockDriver{}
+}
+
+// Allocate implements the HardwareDriver interface.
+func (d *MockDriver) Allocate(ctx context.Context, spec pluginapi.NodeAllocationRequestSpec,
+	owner string) (id NodeID, address BMCAddress, credentials Credentials, err error) {
+	id = NodeID(uuid.NewString())
+	address = "https://mybmc.com"
+	credentials = Credentials{
+		Username: "myuser",
+		Password: "mypass",
+	}
+	return
+}
+
+// Release implements the HardwareDriver interface.
+func (d *MockDriver) Release(ctx context.Context, id NodeID) error {
+	return nil
+}
+
+// Inventory implements the HardwareDriver interface.
+func (d *MockDriver) Inventory(ctx context.Context) ([]Node, error) {
+	return nil, nil
+}
+
+// Name implements the HardwareDriver interface.
+func (d *MockDriver) Name() string {
+	return "mock"
+}
@@ -0,0 +1,220 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// poolConfigMapName is the name of the config map used to store the node pool inventory.
+const poolConfigMapName = "acme-hardware-management-pool"
+
+// poolNodeState is the allocation state of a node of the pool.
+type poolNodeState string
+
+const (
+	// poolNodeFree indicates that a node isn't allocated to any request, and can be picked by
+	// Allocate.
+	poolNodeFree poolNodeState = "free"
+
+	// poolNodeAllocated indicates that a node is currently allocated to the request identified
+	// by the owner field.
+	poolNodeAllocated poolNodeState = "allocated"
+
+	// poolNodeDraining indicates that a node has been taken out of the pool for maintenance, and
+	// should not be picked by Allocate even though it isn't allocated to any request.
+	poolNodeDraining poolNodeState = "draining"
+)
+
+// poolNode is the representation of a node that is persisted in the pool config map.
+type poolNode struct {
+	ID         NodeID            `json:"id"`
+	Location   string            `json:"location"`
+	Extensions map[string]string `json:"extensions"`
+	State      poolNodeState     `json:"state"`
+	Owner      string            `json:"owner,omitempty"`
+}
+
+// PoolDriver is a `HardwareDriver` backed by a fixed inventory of nodes, seeded and persisted in a
+// config map. Unlike MockDriver, it actually tracks which nodes are free, allocated or draining,
+// so that Allocate fails with ErrNoCapacity once the pool is exhausted instead of pretending that
+// hardware is always available. A mutex serializes access to the pool so that concurrent
+// reconciles of different requests can't allocate the same node twice.
+type PoolDriver struct {
+	client    clnt.Client
+	namespace string
+	mutex     sync.Mutex
+}
+
+var _ HardwareDriver = (*PoolDriver)(nil)
+
+// NewPoolDriver creates a driver backed by the node pool stored in the given namespace. The pool
+// is expected to be seeded in advance, for example by an administrator creating or editing the
+// `acme-hardware-management-pool` config map.
+func NewPoolDriver(client clnt.Client, namespace string) *PoolDriver {
+	return &PoolDriver{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+// Allocate implements the HardwareDriver interface. It picks the first free node whose location
+// and extensions satisfy the spec, marks it allocated to the given owner, and persists the
+// change. It returns ErrNoCapacity if no free node satisfies the spec.
+func (d *PoolDriver) Allocate(ctx context.Context, spec pluginapi.NodeAllocationRequestSpec,
+	owner string) (id NodeID, address BMCAddress, credentials Credentials, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	pool, nodes, err := d.load(ctx)
+	if err != nil {
+		return
+	}
+
+	for i := range nodes {
+		node := &nodes[i]
+		if node.State != poolNodeFree {
+			continue
+		}
+		if spec.Location != "" && spec.Location != node.Location {
+			continue
+		}
+		if !matchesExtensions(spec.Extensions, node.Extensions) {
+			continue
+		}
+		node.State = poolNodeAllocated
+		node.Owner = owner
+		err = d.save(ctx, pool, nodes)
+		if err != nil {
+			return
+		}
+		id = node.ID
+		address = BMCAddress(fmt.Sprintf("https://%s.example.com", node.ID))
+		credentials = Credentials{
+			Username: "pooluser",
+			Password: "poolpass",
+		}
+		return
+	}
+
+	err = fmt.Errorf("%w: no node in the pool satisfies location %q and extensions %v",
+		ErrNoCapacity, spec.Location, spec.Extensions)
+	return
+}
+
+// Release implements the HardwareDriver interface. It returns the node with the given identifier
+// to the free state.
+func (d *PoolDriver) Release(ctx context.Context, id NodeID) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	pool, nodes, err := d.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		if nodes[i].ID != id {
+			continue
+		}
+		nodes[i].State = poolNodeFree
+		nodes[i].Owner = ""
+		return d.save(ctx, pool, nodes)
+	}
+	return nil
+}
+
+// Inventory implements the HardwareDriver interface.
+func (d *PoolDriver) Inventory(ctx context.Context) ([]Node, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	_, nodes, err := d.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Node, len(nodes))
+	for i, node := range nodes {
+		result[i] = Node{
+			ID:         node.ID,
+			Location:   node.Location,
+			Extensions: node.Extensions,
+			Allocated:  node.State != poolNodeFree,
+		}
+	}
+	return result, nil
+}
+
+// Name implements the HardwareDriver interface.
+func (d *PoolDriver) Name() string {
+	return "pool"
+}
+
+// load reads the pool config map and decodes its nodes. A missing config map is treated as an
+// empty pool, so that Allocate fails with ErrNoCapacity rather than an unrelated error.
+func (d *PoolDriver) load(ctx context.Context) (pool *corev1.ConfigMap, nodes []poolNode, err error) {
+	pool = &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: d.namespace,
+			Name:      poolConfigMapName,
+		},
+	}
+	err = d.client.Get(ctx, clnt.ObjectKeyFromObject(pool), pool)
+	if err != nil && !apierrors.IsNotFound(err) {
+		err = fmt.Errorf("failed to get pool config map: %w", err)
+		return
+	}
+	err = nil
+	encoded := pool.Data["nodes"]
+	if encoded == "" {
+		return
+	}
+	err = json.Unmarshal([]byte(encoded), &nodes)
+	if err != nil {
+		err = fmt.Errorf("failed to decode pool config map: %w", err)
+		return
+	}
+	return
+}
+
+// save encodes the given nodes and patches them into the pool config map.
+func (d *PoolDriver) save(ctx context.Context, pool *corev1.ConfigMap, nodes []poolNode) error {
+	encoded, err := json.Marshal(nodes)
+	if err != nil {
+		return fmt.Errorf("failed to encode pool config map: %w", err)
+	}
+	_, err = controllerutil.CreateOrPatch(ctx, d.client, pool, func() error {
+		if pool.Data == nil {
+			pool.Data = map[string]string{}
+		}
+		pool.Data["nodes"] = string(encoded)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save pool config map: %w", err)
+	}
+	return nil
+}
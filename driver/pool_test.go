@@ -0,0 +1,95 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestPoolDriverAllocateRelease verifies that a node allocated from the pool is reported as
+// allocated in the inventory, that allocating again fails with ErrNoCapacity, and that the node
+// becomes available again once released.
+func TestPoolDriverAllocateRelease(t *testing.T) {
+	scheme := clnt.NewScheme()
+	err := corev1.AddToScheme(scheme)
+	if err != nil {
+		t.Fatalf("failed to add core types to the scheme: %v", err)
+	}
+	client := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	d := NewPoolDriver(client, "default")
+	nodes := []poolNode{
+		{
+			ID:         "node-0",
+			Location:   "rack-1",
+			Extensions: map[string]string{"cpu_count": "64"},
+			State:      poolNodeFree,
+		},
+	}
+	pool := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: poolConfigMapName},
+	}
+	err = d.save(context.Background(), pool, nodes)
+	if err != nil {
+		t.Fatalf("failed to seed pool: %v", err)
+	}
+
+	ctx := context.Background()
+	spec := pluginapi.NodeAllocationRequestSpec{
+		Location:   "rack-1",
+		Extensions: map[string]string{"cpu_count": "64"},
+	}
+	id, _, _, err := d.Allocate(ctx, spec, "owner-1")
+	if err != nil {
+		t.Fatalf("failed to allocate node: %v", err)
+	}
+	if id != "node-0" {
+		t.Fatalf("expected node 'node-0' to be allocated, got '%s'", id)
+	}
+
+	inventory, err := d.Inventory(ctx)
+	if err != nil {
+		t.Fatalf("failed to list inventory: %v", err)
+	}
+	if !inventory[0].Allocated {
+		t.Fatalf("expected node 'node-0' to be reported as allocated")
+	}
+
+	_, _, _, err = d.Allocate(ctx, spec, "owner-2")
+	if !errors.Is(err, ErrNoCapacity) {
+		t.Fatalf("expected ErrNoCapacity allocating from an exhausted pool, got: %v", err)
+	}
+
+	err = d.Release(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to release node: %v", err)
+	}
+
+	inventory, err = d.Inventory(ctx)
+	if err != nil {
+		t.Fatalf("failed to list inventory: %v", err)
+	}
+	if inventory[0].Allocated {
+		t.Fatalf("expected node 'node-0' to no longer be allocated")
+	}
+}
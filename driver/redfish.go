@@ -0,0 +1,255 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/redfish"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// lockConfigMapName is the name of the config map used to keep track of which systems are
+// already reserved. A config map is used, rather than a Redfish `Oem` property, because not
+// every BMC allows writing arbitrary `Oem` data.
+const lockConfigMapName = "acme-hardware-management-reservations"
+
+// RedfishDriver is a `HardwareDriver` that manages real hardware through the Redfish API exposed
+// by its BMC, using the `github.com/stmcginnis/gofish` client. Candidates are matched against the
+// requested extensions, and reservations are tracked in a config map, since the Redfish API
+// itself has no generic notion of "this system is mine". Redfish has no generic notion of
+// physical location either, so location-scoped allocation requests are rejected rather than
+// silently matched against any system.
+type RedfishDriver struct {
+	address   string
+	username  string
+	password  string
+	insecure  bool
+	client    clnt.Client
+	namespace string
+}
+
+var _ HardwareDriver = (*RedfishDriver)(nil)
+
+// NewRedfishDriver creates a driver that talks to the Redfish service at the given address, using
+// the given credentials. The Kubernetes client and namespace are used to store the config map
+// that tracks which systems are reserved.
+func NewRedfishDriver(address, username, password string, client clnt.Client, namespace string) *RedfishDriver {
+	return &RedfishDriver{
+		address:   address,
+		username:  username,
+		password:  password,
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+// connect opens a session with the Redfish service. Callers are responsible for logging out of
+// the returned client once they are done with it.
+func (d *RedfishDriver) connect(ctx context.Context) (*gofish.APIClient, error) {
+	return gofish.ConnectContext(ctx, gofish.ClientConfig{
+		Endpoint: d.address,
+		Username: d.username,
+		Password: d.password,
+		Insecure: d.insecure,
+	})
+}
+
+// Allocate implements the HardwareDriver interface. It rejects location-scoped requests, since
+// Redfish has no generic notion of physical location, then lists the systems exposed by the
+// Redfish service, picks the first one that isn't already reserved and whose processor count and
+// memory size satisfy the given spec, and reserves it in the lock config map.
+func (d *RedfishDriver) Allocate(ctx context.Context, spec pluginapi.NodeAllocationRequestSpec,
+	owner string) (id NodeID, address BMCAddress, credentials Credentials, err error) {
+	if spec.Location != "" {
+		err = fmt.Errorf("redfish driver doesn't support location-scoped allocation requests, "+
+			"but location '%s' was requested", spec.Location)
+		return
+	}
+
+	client, err := d.connect(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to connect to Redfish service '%s': %w", d.address, err)
+		return
+	}
+	defer client.Logout()
+
+	systems, err := client.Service.Systems()
+	if err != nil {
+		err = fmt.Errorf("failed to list Redfish systems: %w", err)
+		return
+	}
+
+	return d.reserve(ctx, systems, spec, owner)
+}
+
+// reserve picks the first of the given systems that isn't already reserved and that satisfies the
+// spec, and reserves it in the lock config map. It is separated from Allocate so that the
+// reservation bookkeeping can be exercised in tests against hand-built systems, without a live
+// Redfish connection.
+func (d *RedfishDriver) reserve(ctx context.Context, systems []*redfish.ComputerSystem,
+	spec pluginapi.NodeAllocationRequestSpec, owner string) (id NodeID, address BMCAddress,
+	credentials Credentials, err error) {
+	lock := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: d.namespace,
+			Name:      lockConfigMapName,
+		},
+	}
+	err = d.client.Get(ctx, clnt.ObjectKeyFromObject(lock), lock)
+	if err != nil && !apierrors.IsNotFound(err) {
+		err = fmt.Errorf("failed to get lock config map: %w", err)
+		return
+	}
+
+	for _, system := range systems {
+		if lock.Data[system.ID] != "" {
+			continue
+		}
+		if !matchesSpec(system, spec) {
+			continue
+		}
+		_, err = controllerutil.CreateOrPatch(ctx, d.client, lock, func() error {
+			if lock.Data == nil {
+				lock.Data = map[string]string{}
+			}
+			lock.Data[system.ID] = owner
+			return nil
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to reserve system '%s': %w", system.ID, err)
+			return
+		}
+		id = NodeID(system.ID)
+		address = BMCAddress(d.address)
+		credentials = Credentials{
+			Username: d.username,
+			Password: d.password,
+		}
+		return
+	}
+
+	err = fmt.Errorf(
+		"%w: no system at '%s' satisfies location '%s' and extensions %v",
+		ErrNoCapacity, d.address, spec.Location, spec.Extensions,
+	)
+	return
+}
+
+// Release implements the HardwareDriver interface. It removes the reservation of the system with
+// the given identifier from the lock config map.
+func (d *RedfishDriver) Release(ctx context.Context, id NodeID) error {
+	lock := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: d.namespace,
+			Name:      lockConfigMapName,
+		},
+	}
+	err := d.client.Get(ctx, clnt.ObjectKeyFromObject(lock), lock)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get lock config map: %w", err)
+	}
+	_, err = controllerutil.CreateOrPatch(ctx, d.client, lock, func() error {
+		delete(lock.Data, string(id))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release system '%s': %w", id, err)
+	}
+	return nil
+}
+
+// Inventory implements the HardwareDriver interface. It lists the systems exposed by the Redfish
+// service and reports their attributes and reservation state.
+func (d *RedfishDriver) Inventory(ctx context.Context) (result []Node, err error) {
+	client, err := d.connect(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to connect to Redfish service '%s': %w", d.address, err)
+		return
+	}
+	defer client.Logout()
+
+	systems, err := client.Service.Systems()
+	if err != nil {
+		err = fmt.Errorf("failed to list Redfish systems: %w", err)
+		return
+	}
+
+	lock := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: d.namespace,
+			Name:      lockConfigMapName,
+		},
+	}
+	err = d.client.Get(ctx, clnt.ObjectKeyFromObject(lock), lock)
+	if err != nil && !apierrors.IsNotFound(err) {
+		err = fmt.Errorf("failed to get lock config map: %w", err)
+		return
+	}
+
+	result = make([]Node, len(systems))
+	for i, system := range systems {
+		result[i] = Node{
+			ID:         NodeID(system.ID),
+			Extensions: extensionsOf(system),
+			Allocated:  lock.Data[system.ID] != "",
+		}
+	}
+	return
+}
+
+// Name implements the HardwareDriver interface.
+func (d *RedfishDriver) Name() string {
+	return "redfish"
+}
+
+// matchesSpec reports whether the given system satisfies the requested location and extensions.
+// Redfish has no generic notion of the physical location of a system, so a non-empty
+// `spec.Location` never matches here; callers must reject location-scoped requests before relying
+// on this driver, which is what Allocate does.
+func matchesSpec(system *redfish.ComputerSystem, spec pluginapi.NodeAllocationRequestSpec) bool {
+	if spec.Location != "" {
+		return false
+	}
+	actual := extensionsOf(system)
+	for key, value := range spec.Extensions {
+		if actual[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// extensionsOf extracts the CPU count and RAM of a system into the same key/value format used by
+// the `Extensions` field of allocation requests. NIC labels are not included, because gofish
+// doesn't expose them in a form we can map to a stable key; a spec that requests a NIC label
+// extension will therefore never match any system here.
+func extensionsOf(system *redfish.ComputerSystem) map[string]string {
+	return map[string]string{
+		"cpu_count": strconv.Itoa(system.ProcessorSummary.Count),
+		"ram_gib":   strconv.Itoa(int(system.MemorySummary.TotalSystemMemoryGiB)),
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package driver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+	"github.com/stmcginnis/gofish/redfish"
+	corev1 "k8s.io/api/core/v1"
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newComputerSystem builds a `*redfish.ComputerSystem` with the given identifier, processor count
+// and RAM, for use in tests that don't need a live Redfish connection.
+func newComputerSystem(id string, cpuCount int, ramGiB float32) *redfish.ComputerSystem {
+	system := &redfish.ComputerSystem{}
+	system.ID = id
+	system.ProcessorSummary.Count = cpuCount
+	system.MemorySummary.TotalSystemMemoryGiB = ramGiB
+	return system
+}
+
+// TestRedfishMatchesSpec verifies that matchesSpec accepts systems whose CPU count and RAM satisfy
+// the requested extensions, and rejects both mismatched extensions and location-scoped requests,
+// since Redfish has no generic notion of physical location.
+func TestRedfishMatchesSpec(t *testing.T) {
+	system := newComputerSystem("system-0", 64, 128)
+
+	matches := matchesSpec(system, pluginapi.NodeAllocationRequestSpec{
+		Extensions: map[string]string{"cpu_count": "64", "ram_gib": "128"},
+	})
+	if !matches {
+		t.Fatalf("expected system to match extensions that agree with its CPU count and RAM")
+	}
+
+	matches = matchesSpec(system, pluginapi.NodeAllocationRequestSpec{
+		Extensions: map[string]string{"cpu_count": "32"},
+	})
+	if matches {
+		t.Fatalf("expected system not to match a CPU count extension that disagrees with it")
+	}
+
+	matches = matchesSpec(system, pluginapi.NodeAllocationRequestSpec{
+		Location: "rack-1",
+	})
+	if matches {
+		t.Fatalf("expected a location-scoped spec never to match, since Redfish has no notion of location")
+	}
+}
+
+// TestRedfishExtensionsOf verifies that extensionsOf extracts the CPU count and RAM of a system
+// into the key/value format used by the `Extensions` field of allocation requests.
+func TestRedfishExtensionsOf(t *testing.T) {
+	system := newComputerSystem("system-0", 64, 128)
+	extensions := extensionsOf(system)
+	if extensions["cpu_count"] != "64" {
+		t.Fatalf("expected cpu_count extension '64', got '%s'", extensions["cpu_count"])
+	}
+	if extensions["ram_gib"] != "128" {
+		t.Fatalf("expected ram_gib extension '128', got '%s'", extensions["ram_gib"])
+	}
+}
+
+// TestRedfishReserveRelease verifies that reserving a system records the reservation in the lock
+// config map, that reserving it again fails with ErrNoCapacity, and that the system becomes
+// available again once released.
+func TestRedfishReserveRelease(t *testing.T) {
+	scheme := clnt.NewScheme()
+	err := corev1.AddToScheme(scheme)
+	if err != nil {
+		t.Fatalf("failed to add core types to the scheme: %v", err)
+	}
+	client := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	d := NewRedfishDriver("https://bmc.example.com", "user", "pass", client, "default")
+	systems := []*redfish.ComputerSystem{newComputerSystem("system-0", 64, 128)}
+
+	ctx := context.Background()
+	spec := pluginapi.NodeAllocationRequestSpec{
+		Extensions: map[string]string{"cpu_count": "64"},
+	}
+	id, _, _, err := d.reserve(ctx, systems, spec, "owner-1")
+	if err != nil {
+		t.Fatalf("failed to reserve system: %v", err)
+	}
+	if id != "system-0" {
+		t.Fatalf("expected system 'system-0' to be reserved, got '%s'", id)
+	}
+
+	_, _, _, err = d.reserve(ctx, systems, spec, "owner-2")
+	if !errors.Is(err, ErrNoCapacity) {
+		t.Fatalf("expected ErrNoCapacity reserving an already reserved system, got: %v", err)
+	}
+
+	err = d.Release(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to release system: %v", err)
+	}
+
+	id, _, _, err = d.reserve(ctx, systems, spec, "owner-3")
+	if err != nil {
+		t.Fatalf("failed to reserve system after it was released: %v", err)
+	}
+	if id != "system-0" {
+		t.Fatalf("expected system 'system-0' to be reserved again, got '%s'", id)
+	}
+}
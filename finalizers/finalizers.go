@@ -0,0 +1,63 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+// Package finalizers provides a small registry of finalizer callbacks that reconcilers can share,
+// instead of each one hand-rolling `ContainsFinalizer`/`AddFinalizer`/`RemoveFinalizer` around its
+// own cleanup logic. It is a thin wrapper around `sigs.k8s.io/controller-runtime/pkg/finalizer`.
+package finalizers
+
+import (
+	"context"
+
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	crfinalizer "sigs.k8s.io/controller-runtime/pkg/finalizer"
+)
+
+// Func is an adapter that allows a plain function to be registered as a finalizer, in the same
+// way that `http.HandlerFunc` adapts a function to an `http.Handler`.
+type Func func(ctx context.Context, object clnt.Object) (crfinalizer.Result, error)
+
+// Finalize calls f.
+func (f Func) Finalize(ctx context.Context, object clnt.Object) (crfinalizer.Result, error) {
+	return f(ctx, object)
+}
+
+// Registry collects the finalizers used by a reconciler and runs all of them together. Callers
+// register their cleanup logic once, at manager setup, with Register, and the reconcile loop then
+// only needs to call Finalize.
+type Registry struct {
+	finalizers crfinalizer.Finalizers
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		finalizers: crfinalizer.NewFinalizers(),
+	}
+}
+
+// Register adds the given function to the registry under the given key. The key is used both as
+// the finalizer string stored in the object metadata and to look up the function again later, so
+// it should be a fully qualified name, for example `hardwaremanagement.oran.openshift.io/bmc`.
+func (r *Registry) Register(key string, f Func) error {
+	return r.finalizers.Register(key, f)
+}
+
+// Finalize adds the finalizers that are missing from the object, or, if the object is being
+// deleted, runs the registered functions for the finalizers that are present and removes them
+// once they succeed. The returned result indicates whether the object metadata or status were
+// changed, so that the caller knows whether it needs to patch them.
+func (r *Registry) Finalize(ctx context.Context, object clnt.Object) (crfinalizer.Result, error) {
+	return r.finalizers.Finalize(ctx, object)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package finalizers
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	crfinalizer "sigs.k8s.io/controller-runtime/pkg/finalizer"
+)
+
+// TestRegistryAddsMissingFinalizer verifies that finalizing an object that isn't being deleted
+// adds the registered finalizer.
+func TestRegistryAddsMissingFinalizer(t *testing.T) {
+	registry := NewRegistry()
+	called := false
+	err := registry.Register("example.com/finalizer", Func(
+		func(ctx context.Context, object clnt.Object) (crfinalizer.Result, error) {
+			called = true
+			return crfinalizer.Result{}, nil
+		},
+	))
+	if err != nil {
+		t.Fatalf("failed to register finalizer: %v", err)
+	}
+
+	object := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "my-object",
+		},
+	}
+	result, err := registry.Finalize(context.Background(), object)
+	if err != nil {
+		t.Fatalf("failed to finalize object: %v", err)
+	}
+	if !result.Updated {
+		t.Fatalf("expected the finalizer to have been added")
+	}
+	if called {
+		t.Fatalf("didn't expect the finalizer function to run for an object that isn't being deleted")
+	}
+}
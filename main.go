@@ -15,22 +15,49 @@ License.
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
+	"time"
 
+	"github.com/jhernand/acme-hardware-management-plugin/driver"
 	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// finalizerName is added to the allocation and release requests that we are currently
+// processing, so that we have a chance to run our cleanup logic before they are deleted.
+const finalizerName = "hardwaremanagement.oran.openshift.io/finalizer"
+
 func main() {
+	// Parse the command line flags that select and configure the hardware driver:
+	driverName := flag.String("driver", "mock", "Hardware driver to use, one of 'mock', 'pool' or 'redfish'.")
+	redfishAddress := flag.String("redfish-address", "", "Address of the Redfish service, for example 'https://mybmc.com'.")
+	redfishUsername := flag.String("redfish-username", "", "Username used to authenticate with the Redfish service.")
+	redfishPassword := flag.String("redfish-password", "", "Password used to authenticate with the Redfish service.")
+	namespace := flag.String("namespace", "default", "Namespace used to store internal state, such as the Redfish reservations config map.")
+	metricsAddress := flag.String("metrics-bind-address", ":8080", "Address the metrics endpoint, served at '/metrics', binds to.")
+	flag.Parse()
+
 	// Create a logger and configure libraries to use it:
 	logger := zap.New()
 	ctrl.SetLogger(logger)
 	klog.SetLogger(logger)
 
-	// Create the controller manager:
-	manager, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{})
+	// Create the controller manager. Metrics, including the ones registered by the metrics
+	// package, are served at '/metrics' on the configured address.
+	manager, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Metrics: metricsserver.Options{
+			BindAddress: *metricsAddress,
+		},
+	})
 	if err != nil {
 		logger.Error(err, "Failed to create controller manager")
 		os.Exit(1)
@@ -43,25 +70,48 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create the allocation request reconciler:
+	// Create the hardware driver selected with the `--driver` flag:
+	hardware, err := newDriver(*driverName, *redfishAddress, *redfishUsername, *redfishPassword, *namespace,
+		manager.GetClient())
+	if err != nil {
+		logger.Error(err, "Failed to create hardware driver")
+		os.Exit(1)
+	}
+
+	// Use an exponential backoff rate limiter for both controllers, so that requests that keep
+	// failing are retried with increasing delays instead of hammering the driver and the API
+	// server. Requests that find no capacity don't fail; they are requeued after the fixed
+	// noCapacityRequeueInterval, which the rate limiter doesn't increase:
+	rateLimiter := workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](5*time.Millisecond, 5*time.Minute)
+
+	// Create the allocation request reconciler, and register the finalizer that releases the
+	// BMC state of the node and deletes its credentials secret:
+	allocationReconciler, err := NewAllocationReconciler(logger.WithName("allocation"), manager.GetClient(), hardware,
+		manager.GetEventRecorderFor("allocation-controller"))
+	if err != nil {
+		logger.Error(err, "Failed to create allocation reconciler")
+		os.Exit(1)
+	}
 	err = ctrl.NewControllerManagedBy(manager).
 		For(&pluginapi.NodeAllocationRequest{}).
-		Complete(&AllocationReconciler{
-			logger: logger.WithName("allocation"),
-			client: manager.GetClient(),
-		})
+		WithOptions(controller.Options{RateLimiter: rateLimiter}).
+		Complete(allocationReconciler)
 	if err != nil {
 		logger.Error(err, "Failed to create allocation reconciler")
 		os.Exit(1)
 	}
 
-	// Create the release request reconciler:
+	// Create the release request reconciler, and register its finalizer:
+	releaseReconciler, err := NewReleaseReconciler(logger.WithName("release"), manager.GetClient(), hardware,
+		manager.GetEventRecorderFor("release-controller"))
+	if err != nil {
+		logger.Error(err, "Failed to create release reconciler")
+		os.Exit(1)
+	}
 	err = ctrl.NewControllerManagedBy(manager).
 		For(&pluginapi.NodeReleaseRequest{}).
-		Complete(&ReleaseReconciler{
-			logger: logger.WithName("release"),
-			client: manager.GetClient(),
-		})
+		WithOptions(controller.Options{RateLimiter: rateLimiter}).
+		Complete(releaseReconciler)
 	if err != nil {
 		logger.Error(err, "Failed to create release reconciler")
 		os.Exit(1)
@@ -75,3 +125,22 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newDriver creates the hardware driver selected by name, which must be one of 'mock', 'pool' or
+// 'redfish'. The Redfish address, username and password are only used when 'redfish' is selected;
+// the client and namespace are used to store the Redfish reservations config map and the pool
+// inventory config map.
+func newDriver(name, redfishAddress, redfishUsername, redfishPassword, namespace string,
+	client clnt.Client) (result driver.HardwareDriver, err error) {
+	switch name {
+	case "mock":
+		result = driver.NewMockDriver()
+	case "pool":
+		result = driver.NewPoolDriver(client, namespace)
+	case "redfish":
+		result = driver.NewRedfishDriver(redfishAddress, redfishUsername, redfishPassword, client, namespace)
+	default:
+		err = fmt.Errorf("unknown hardware driver '%s', must be one of 'mock', 'pool' or 'redfish'", name)
+	}
+	return
+}
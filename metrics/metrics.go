@@ -0,0 +1,85 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+// Package metrics registers the Prometheus metrics published by this plugin, and is served by the
+// controller-runtime manager's built-in metrics server at `/metrics`:
+//
+//   - hwmgmt_allocations_total{result}: counter of allocation attempts, where result is one of
+//     "success", "no_capacity" or "error".
+//   - hwmgmt_releases_total{result}: counter of release attempts, where result is one of
+//     "success" or "error".
+//   - hwmgmt_allocation_duration_seconds: histogram of the time elapsed between the creation of
+//     an allocation request and the moment it is first fulfilled.
+//   - hwmgmt_bmc_request_duration_seconds{driver,op}: histogram of the time taken by calls to the
+//     hardware driver, where driver is the name of the driver implementation (for example "mock"
+//     or "redfish") and op is one of "allocate" or "release".
+//   - hwmgmt_nodes_by_state{state}: gauge of the number of nodes known to the driver, where state
+//     is one of "free" or "allocated". Drivers that don't support Inventory leave this untouched.
+package metrics
+
+import (
+	"github.com/jhernand/acme-hardware-management-plugin/driver"
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var AllocationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "hwmgmt_allocations_total",
+	Help: "Total number of node allocation attempts, by result.",
+}, []string{"result"})
+
+var ReleasesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "hwmgmt_releases_total",
+	Help: "Total number of node release attempts, by result.",
+}, []string{"result"})
+
+var AllocationDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+	Name:    "hwmgmt_allocation_duration_seconds",
+	Help:    "Time elapsed between the creation of an allocation request and the moment it is first fulfilled.",
+	Buckets: prometheus.DefBuckets,
+})
+
+var BMCRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "hwmgmt_bmc_request_duration_seconds",
+	Help:    "Time taken by requests made to the hardware driver, by driver and operation.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"driver", "op"})
+
+var NodesByState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "hwmgmt_nodes_by_state",
+	Help: "Number of nodes known to the driver, by state.",
+}, []string{"state"})
+
+func init() {
+	crmetrics.Registry.MustRegister(AllocationsTotal, ReleasesTotal, AllocationDuration, BMCRequestDuration, NodesByState)
+}
+
+// RecordNodesByState updates the hwmgmt_nodes_by_state gauge from the given inventory. Drivers
+// that don't support Inventory, such as MockDriver, return an empty list, in which case the gauge
+// is left untouched rather than being reset to zero.
+func RecordNodesByState(nodes []driver.Node) {
+	if len(nodes) == 0 {
+		return
+	}
+	var free, allocated int
+	for _, node := range nodes {
+		if node.Allocated {
+			allocated++
+		} else {
+			free++
+		}
+	}
+	NodesByState.WithLabelValues("free").Set(float64(free))
+	NodesByState.WithLabelValues("allocated").Set(float64(allocated))
+}
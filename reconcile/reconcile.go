@@ -0,0 +1,166 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+// Package reconcile provides a generic scaffold for the fetch, finalize, process and patch
+// pattern shared by all of the reconcilers of this plugin, so that each one only needs to
+// provide the object type and the callback that does the actual work.
+package reconcile
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/jhernand/acme-hardware-management-plugin/finalizers"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ProcessFunc is the callback that does the actual work once an object has been fetched and the
+// finalizer handling has been taken care of. It should only modify the status of the object;
+// changes to anything else won't be persisted.
+type ProcessFunc[T clnt.Object] func(ctx context.Context, object T) (reconcile.Result, error)
+
+// Reconciler implements the fetch, finalize, process and patch pattern that is common to all of
+// the reconcilers of this plugin. T is the type of object being reconciled, for example
+// `*pluginapi.NodeAllocationRequest`.
+type Reconciler[T clnt.Object] struct {
+	// Logger is used to write log messages.
+	Logger logr.Logger
+
+	// Client is used to get and patch the reconciled objects.
+	Client clnt.Client
+
+	// New creates a new, empty, instance of the reconciled object, so that it can be passed to
+	// Client.Get.
+	New func() T
+
+	// Finalizers is the registry used to add the finalizer to new objects and to run the
+	// cleanup logic when objects are deleted.
+	Finalizers *finalizers.Registry
+
+	// ProcessUpdate does the actual work when an object has been created or updated.
+	ProcessUpdate ProcessFunc[T]
+}
+
+// Reconcile fetches the object, runs the registered finalizers, and, unless the object is being
+// deleted or the finalizers have just been added, calls ProcessUpdate and patches the resulting
+// status. A reconcile that adds the finalizers only patches them, and ProcessUpdate runs on the
+// next one.
+func (r *Reconciler[T]) Reconcile(ctx context.Context,
+	request ctrl.Request) (result ctrl.Result, err error) {
+	// Fetch the object object:
+	object := r.New()
+	err = r.Client.Get(ctx, request.NamespacedName, object)
+	if apierrors.IsNotFound(err) {
+		r.Logger.Info(
+			"Object no longer exists",
+			"namespace", request.Namespace,
+			"name", request.Name,
+		)
+		err = nil
+		return
+	}
+	if err != nil {
+		r.Logger.Error(err, "Failed to get object")
+		return
+	}
+
+	// Make a copy of the object so that we can modify it during our processing, and calculate
+	// the changes from the original to make a patch when we are done.
+	copy := object.DeepCopyObject().(T)
+
+	// Run the registered finalizers. If the object isn't being deleted this adds our finalizer,
+	// if it is missing. If the object is being deleted this runs our cleanup logic and removes
+	// our finalizer once it succeeds. Either way it tells us whether the metadata or the status
+	// were changed, so that we know what to patch.
+	finalizeResult, err := r.Finalizers.Finalize(ctx, copy)
+	if err != nil {
+		r.Logger.Error(
+			err,
+			"Failed to run finalizers",
+			"namespace", request.Namespace,
+			"name", request.Name,
+		)
+		return
+	}
+	if finalizeResult.StatusUpdated {
+		err = r.Client.Status().Patch(ctx, copy, clnt.MergeFrom(object))
+		if err != nil {
+			r.Logger.Error(
+				err,
+				"Failed to updated status",
+				"namespace", request.Namespace,
+				"name", request.Name,
+			)
+			return
+		}
+	}
+	if finalizeResult.Updated {
+		err = r.Client.Patch(ctx, copy, clnt.MergeFrom(object))
+		if err != nil {
+			r.Logger.Error(
+				err,
+				"Failed to update finalizers",
+				"namespace", request.Namespace,
+				"name", request.Name,
+			)
+		}
+		return
+	}
+
+	// If the object is being deleted then there is nothing else to do: either our finalizer
+	// was removed above, or it was never present in the first place.
+	if !object.GetDeletionTimestamp().IsZero() {
+		return
+	}
+
+	// If we are here then the object was just created or updated, and it already has our
+	// finalizer, so we must do our update processing and save the updated status.
+	result, err = r.ProcessUpdate(ctx, copy)
+	if err != nil {
+		r.Logger.Error(
+			err,
+			"Failed to process update",
+			"namespace", request.Namespace,
+			"name", request.Name,
+		)
+		return
+	}
+
+	// Avoid a spurious patch, and the resulting extra reconcile, if ProcessUpdate didn't
+	// actually change anything:
+	if equality.Semantic.DeepEqual(object, copy) {
+		return
+	}
+	err = r.Client.Status().Patch(ctx, copy, clnt.MergeFrom(object))
+	if err != nil {
+		r.Logger.Error(
+			err,
+			"Failed to updated status",
+			"namespace", request.Namespace,
+			"name", request.Name,
+		)
+		return
+	}
+	r.Logger.Info(
+		"Saved updated status",
+		"namespace", request.Namespace,
+		"name", request.Name,
+	)
+
+	return
+}
@@ -16,139 +16,66 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/jhernand/acme-hardware-management-plugin/driver"
+	"github.com/jhernand/acme-hardware-management-plugin/finalizers"
+	"github.com/jhernand/acme-hardware-management-plugin/metrics"
+	genreconcile "github.com/jhernand/acme-hardware-management-plugin/reconcile"
 	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	ctrl "sigs.k8s.io/controller-runtime"
+	"k8s.io/client-go/tools/record"
 	clnt "sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	crfinalizer "sigs.k8s.io/controller-runtime/pkg/finalizer"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // ReleaseReconciler contains the data and logic needed to reconcile request to release allocated
-// nodes.
+// nodes. The fetch, finalize and patch boilerplate lives in the embedded generic reconciler; this
+// type only provides the update and delete callbacks.
 type ReleaseReconciler struct {
-	logger logr.Logger
+	*genreconcile.Reconciler[*pluginapi.NodeReleaseRequest]
 	client clnt.Client
+	driver driver.HardwareDriver
+	events record.EventRecorder
 }
 
-// Reconcile is the method that will be called by the controller runtime library when a request
-// to release a node has been created, updated or deleted.
-func (r *ReleaseReconciler) Reconcile(ctx context.Context,
-	request ctrl.Request) (result ctrl.Result, err error) {
-	// Fetch the object object:
-	object := &pluginapi.NodeReleaseRequest{}
-	err = r.client.Get(ctx, request.NamespacedName, object)
-	if apierrors.IsNotFound(err) {
-		r.logger.Info(
-			"Object no longer exists",
-			"namespace", request.Namespace,
-			"name", request.Name,
-		)
-		err = nil
-		return
+// NewReleaseReconciler creates a reconciler for release requests, and registers its finalizer.
+// Nodes are released through the given driver, and release outcomes are reported through the
+// given event recorder.
+func NewReleaseReconciler(logger logr.Logger, client clnt.Client, hardware driver.HardwareDriver,
+	events record.EventRecorder) (result *ReleaseReconciler, err error) {
+	r := &ReleaseReconciler{
+		client: client,
+		driver: hardware,
+		events: events,
 	}
+	registry := finalizers.NewRegistry()
+	err = registry.Register(finalizerName, r.processDelete)
 	if err != nil {
-		r.logger.Error(err, "Failed to get object")
 		return
 	}
-
-	// Check if the object is being deleted and if it has our finalizer:
-	deleting := !object.DeletionTimestamp.IsZero()
-	finalizer := controllerutil.ContainsFinalizer(object, finalizerName)
-
-	// Make a copy of the object so that we can modify it during our processing, and calculate
-	// the changes from the original to make a patch when we are done.
-	copy := object.DeepCopy()
-
-	// If the object isn't being deleted and doesn't have our finalizeer then we need to add
-	// the finalizer and save it inmediately, so that when it is eventually deleted we will
-	// have time to do our cleanup actions. This will generate another call to our reconciler
-	// where we will do the real work.
-	if !deleting && !finalizer {
-		controllerutil.AddFinalizer(copy, finalizerName)
-		err = r.client.Patch(ctx, copy, clnt.MergeFrom(object))
-		if err != nil {
-			r.logger.Error(
-				err,
-				"Failed to add finalizer",
-				"namespace", request.Namespace,
-				"name", request.Name,
-				"finalizer", finalizerName,
-			)
-		}
-		return
+	r.Reconciler = &genreconcile.Reconciler[*pluginapi.NodeReleaseRequest]{
+		Logger: logger,
+		Client: client,
+		New: func() *pluginapi.NodeReleaseRequest {
+			return &pluginapi.NodeReleaseRequest{}
+		},
+		Finalizers:    registry,
+		ProcessUpdate: r.processUpdate,
 	}
-
-	// If the object is being deleted then we need to do our cleaning actions, save the updated
-	// status and remove the finalizer.
-	if deleting {
-		result, err = r.processDelete(ctx, copy)
-		if err != nil {
-			return
-		}
-		err = r.client.Status().Patch(ctx, copy, clnt.MergeFrom(object))
-		if err != nil {
-			r.logger.Error(
-				err,
-				"Failed to updated status",
-				"namespace", request.Namespace,
-				"name", request.Name,
-			)
-			return
-		}
-		controllerutil.RemoveFinalizer(copy, finalizerName)
-		err = r.client.Patch(ctx, copy, clnt.MergeFrom(object))
-		if err != nil {
-			r.logger.Error(
-				err,
-				"Failed to remove finalizer",
-				"namespace", request.Namespace,
-				"name", request.Name,
-				"finalizer", finalizerName,
-			)
-		}
-		return
-	}
-
-	// If we are here then the object was just created or updated, and it already has our
-	// finalizer, so we must do our update processing and save the updated status.
-	result, err = r.processUpdate(ctx, copy)
-	if err != nil {
-		r.logger.Error(
-			err,
-			"Failed to process update",
-			"namespace", request.Namespace,
-			"name", request.Name,
-		)
-		return
-	}
-	err = r.client.Status().Patch(ctx, copy, clnt.MergeFrom(object))
-	if err != nil {
-		r.logger.Error(
-			err,
-			"Failed to updated status",
-			"namespace", request.Namespace,
-			"name", request.Name,
-		)
-		return
-	}
-	r.logger.Info(
-		"Saved updated status",
-		"namespace", request.Namespace,
-		"name", request.Name,
-	)
-
+	result = r
 	return
 }
 
 func (r *ReleaseReconciler) processUpdate(ctx context.Context,
 	object *pluginapi.NodeReleaseRequest) (result reconcile.Result, err error) {
 	// Inform in the log that we are fulfilling the request:
-	r.logger.Info(
+	r.Logger.Info(
 		"Fulfilling request",
 		"namespace", object.Namespace,
 		"name", object.Name,
@@ -157,7 +84,33 @@ func (r *ReleaseReconciler) processUpdate(ctx context.Context,
 		"extensions", object.Spec.Extensions,
 	)
 
-	// Do the actual processing ...
+	// Remember whether the request was already fulfilled, so that we only release the node and
+	// count and report the release once, rather than on every reconcile.
+	alreadyFulfilled := meta.IsStatusConditionTrue(object.Status.Conditions, pluginapi.FulfilledCondition)
+
+	// Ask the driver to release the node, so that it becomes available for other allocation
+	// requests. Once a release has been fulfilled we don't ask the driver to release the node
+	// again on every reconcile.
+	if !alreadyFulfilled {
+		start := time.Now()
+		err = r.driver.Release(ctx, driver.NodeID(object.Spec.NodeID))
+		metrics.BMCRequestDuration.WithLabelValues(r.driver.Name(), "release").Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.ReleasesTotal.WithLabelValues("error").Inc()
+			r.events.Eventf(object, corev1.EventTypeWarning, "ReleaseFailed",
+				"Failed to release node %q: %v", object.Spec.NodeID, err)
+			return
+		}
+		metrics.ReleasesTotal.WithLabelValues("success").Inc()
+		r.events.Eventf(object, corev1.EventTypeNormal, "Released", "Released node %q", object.Spec.NodeID)
+
+		// Only refresh the inventory gauge when we actually released a node. Refreshing it on
+		// every reconcile of an already-fulfilled request would mean a Redfish round-trip to the
+		// BMC just to keep a gauge warm.
+		if nodes, invErr := r.driver.Inventory(ctx); invErr == nil {
+			metrics.RecordNodesByState(nodes)
+		}
+	}
 
 	// Update the conditions:
 	meta.SetStatusCondition(&object.Status.Conditions, metav1.Condition{
@@ -168,7 +121,7 @@ func (r *ReleaseReconciler) processUpdate(ctx context.Context,
 	})
 
 	// Inform in the log that the request is fulfilled:
-	r.logger.Info(
+	r.Logger.Info(
 		"Fulfilled request",
 		"namespace", object.Namespace,
 		"name", object.Name,
@@ -180,12 +133,19 @@ func (r *ReleaseReconciler) processUpdate(ctx context.Context,
 	return
 }
 
+// processDelete is registered as the finalizer function for release requests, so its signature
+// is dictated by finalizers.Func rather than by the reconciler itself.
 func (r *ReleaseReconciler) processDelete(ctx context.Context,
-	object *pluginapi.NodeReleaseRequest) (result reconcile.Result, err error) {
-	r.logger.Info(
+	object clnt.Object) (result crfinalizer.Result, err error) {
+	release, ok := object.(*pluginapi.NodeReleaseRequest)
+	if !ok {
+		err = fmt.Errorf("expected a node release request but got object of type %T", object)
+		return
+	}
+	r.Logger.Info(
 		"Performing cleanup",
-		"namespace", object.GetNamespace(),
-		"name", object.GetName(),
+		"namespace", release.GetNamespace(),
+		"name", release.GetName(),
 	)
 	return
 }
@@ -0,0 +1,72 @@
+/*
+Copyright (c) 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file except in
+compliance with the License. You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is
+distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+implied. See the License for the specific language governing permissions and limitations under the
+License.
+*/
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/jhernand/acme-hardware-management-plugin/driver"
+	pluginapi "github.com/openshift-kni/oran-o2ims/api/hardwaremanagement/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	clnt "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestReleaseReconcilerDeleteBeforeFinalizer verifies that reconciling a release request that
+// has already been marked for deletion, but never got our finalizer added, doesn't fail trying
+// to add it. The fake client used here has no cache, so the delete is visible to the very next
+// `Get`, the same situation that a stale informer cache can produce in a real cluster.
+func TestReleaseReconcilerDeleteBeforeFinalizer(t *testing.T) {
+	scheme := clnt.NewScheme()
+	err := pluginapi.AddToScheme(scheme)
+	if err != nil {
+		t.Fatalf("failed to add plugin API types to the scheme: %v", err)
+	}
+
+	object := &pluginapi.NodeReleaseRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:  "default",
+			Name:       "my-request",
+			Finalizers: []string{"other.example.com/finalizer"},
+		},
+	}
+	client := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(object).
+		WithStatusSubresource(object).
+		Build()
+
+	ctx := context.Background()
+	err = client.Delete(ctx, object)
+	if err != nil {
+		t.Fatalf("failed to delete object: %v", err)
+	}
+
+	reconciler, err := NewReleaseReconciler(logr.Discard(), client, driver.NewMockDriver(), record.NewFakeRecorder(10))
+	if err != nil {
+		t.Fatalf("failed to create reconciler: %v", err)
+	}
+	request := ctrl.Request{
+		NamespacedName: clnt.ObjectKeyFromObject(object),
+	}
+	_, err = reconciler.Reconcile(ctx, request)
+	if err != nil {
+		t.Fatalf("expected no error reconciling a deleted object without our finalizer, got: %v", err)
+	}
+}